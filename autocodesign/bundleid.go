@@ -0,0 +1,38 @@
+package autocodesign
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+)
+
+// widget mirrors the root element of a Cordova config.xml, just enough to
+// read the app's bundle/package identifier out of it.
+type widget struct {
+	XMLName xml.Name `xml:"widget"`
+	ID      string   `xml:"id,attr"`
+}
+
+// BundleID reads the `id` attribute off the `widget` root element of a
+// Cordova config.xml, which Cordova uses as the iOS bundle identifier / the
+// Android package name.
+func BundleID(configXMLPath string) (string, error) {
+	f, err := os.Open(configXMLPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %s", configXMLPath, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var w widget
+	if err := xml.NewDecoder(f).Decode(&w); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %s", configXMLPath, err)
+	}
+
+	if w.ID == "" {
+		return "", fmt.Errorf("%s has no widget id attribute", configXMLPath)
+	}
+
+	return w.ID, nil
+}
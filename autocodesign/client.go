@@ -0,0 +1,186 @@
+// Package autocodesign resolves and installs iOS and Android code signing
+// credentials automatically via the currently running Bitrise build's
+// connected Apple Developer Portal / Google Play accounts, so a step does not
+// have to be given an explicit provisioning profile, certificate or keystore.
+package autocodesign
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Client talks to the Bitrise build's connected Apple Developer Portal and
+// Google Play credential APIs to resolve code signing files for the app
+// running the current build.
+type Client struct {
+	buildURL   string
+	buildToken string
+	httpClient *http.Client
+}
+
+// NewClient creates a Client authenticated against the currently running
+// Bitrise build, as provided by the BITRISE_BUILD_URL and
+// BITRISE_BUILD_API_TOKEN environment variables which Bitrise.io exports to
+// every step.
+func NewClient() (*Client, error) {
+	buildURL := os.Getenv("BITRISE_BUILD_URL")
+	buildToken := os.Getenv("BITRISE_BUILD_API_TOKEN")
+	if buildURL == "" || buildToken == "" {
+		return nil, fmt.Errorf("automatic code signing requires a bitrise.io build (BITRISE_BUILD_URL / BITRISE_BUILD_API_TOKEN are not set)")
+	}
+
+	return &Client{buildURL: buildURL, buildToken: buildToken, httpClient: http.DefaultClient}, nil
+}
+
+// IOSCredentials is the resolved signing material for one iOS bundle ID.
+type IOSCredentials struct {
+	ProvisioningProfilePath string
+	CodeSignIdentity        string
+	DevelopmentTeam         string
+}
+
+// AndroidCredentials is the resolved signing material for one Android
+// package name.
+type AndroidCredentials struct {
+	KeystorePath       string
+	KeystorePassword   string
+	KeystoreAlias      string
+	PrivateKeyPassword string
+}
+
+type codesigningFilesResponse struct {
+	ProvisioningProfileURL string `json:"provisioning_profile_url"`
+	CertificateURL         string `json:"certificate_url"`
+	CodeSignIdentity       string `json:"code_sign_identity"`
+	DevelopmentTeam        string `json:"development_team"`
+}
+
+type keystoreResponse struct {
+	KeystoreURL        string `json:"keystore_url"`
+	KeystorePassword   string `json:"keystore_password"`
+	KeystoreAlias      string `json:"keystore_alias"`
+	PrivateKeyPassword string `json:"private_key_password"`
+}
+
+// ResolveIOS downloads the provisioning profile and certificate matching
+// bundleID for configuration (e.g. "debug" resolving a development profile,
+// "release" a distribution one), installs the certificate into the login
+// keychain and the provisioning profile into
+// ~/Library/MobileDevice/Provisioning Profiles, and returns the values to put
+// into build.json. Each configuration resolves and is written to its own
+// file, so resolving more than one configuration in a run doesn't clobber
+// an earlier configuration's files on disk.
+func (c *Client) ResolveIOS(bundleID, configuration, dstDir string) (IOSCredentials, error) {
+	query := url.Values{"bundle_id": {bundleID}, "configuration": {configuration}}
+
+	var resp codesigningFilesResponse
+	if err := c.getJSON("/apple_developer_portal/provisioning_profiles?"+query.Encode(), &resp); err != nil {
+		return IOSCredentials{}, fmt.Errorf("failed to look up provisioning profile for %s (%s): %s", bundleID, configuration, err)
+	}
+
+	profilePath := filepath.Join(dstDir, fmt.Sprintf("profile_%s.mobileprovision", configuration))
+	if err := c.downloadFile(resp.ProvisioningProfileURL, profilePath); err != nil {
+		return IOSCredentials{}, fmt.Errorf("failed to download provisioning profile: %s", err)
+	}
+	if err := installProvisioningProfile(profilePath); err != nil {
+		return IOSCredentials{}, err
+	}
+
+	certPath := filepath.Join(dstDir, fmt.Sprintf("certificate_%s.p12", configuration))
+	if err := c.downloadFile(resp.CertificateURL, certPath); err != nil {
+		return IOSCredentials{}, fmt.Errorf("failed to download certificate: %s", err)
+	}
+	if err := installCertificate(certPath); err != nil {
+		return IOSCredentials{}, err
+	}
+
+	return IOSCredentials{
+		ProvisioningProfilePath: profilePath,
+		CodeSignIdentity:        resp.CodeSignIdentity,
+		DevelopmentTeam:         resp.DevelopmentTeam,
+	}, nil
+}
+
+// ResolveAndroid downloads the keystore registered for packageName and
+// configuration (e.g. a debug keystore vs. a Play Store release keystore)
+// and returns the values to put into build.json. Each configuration resolves
+// and is written to its own file, so resolving more than one configuration
+// in a run doesn't clobber an earlier configuration's keystore on disk.
+func (c *Client) ResolveAndroid(packageName, configuration, dstDir string) (AndroidCredentials, error) {
+	query := url.Values{"package_name": {packageName}, "configuration": {configuration}}
+
+	var resp keystoreResponse
+	if err := c.getJSON("/google_play/keystores?"+query.Encode(), &resp); err != nil {
+		return AndroidCredentials{}, fmt.Errorf("failed to look up keystore for %s (%s): %s", packageName, configuration, err)
+	}
+
+	keystorePath := filepath.Join(dstDir, fmt.Sprintf("keystore_%s.jks", configuration))
+	if err := c.downloadFile(resp.KeystoreURL, keystorePath); err != nil {
+		return AndroidCredentials{}, fmt.Errorf("failed to download keystore: %s", err)
+	}
+
+	return AndroidCredentials{
+		KeystorePath:       keystorePath,
+		KeystorePassword:   resp.KeystorePassword,
+		KeystoreAlias:      resp.KeystoreAlias,
+		PrivateKeyPassword: resp.PrivateKeyPassword,
+	}, nil
+}
+
+func (c *Client) getJSON(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.buildURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+c.buildToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *Client) downloadFile(url, pth string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+c.buildToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	out, err := os.Create(pth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
@@ -0,0 +1,47 @@
+package autocodesign
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/command"
+)
+
+// installCertificate imports a downloaded p12 certificate into the user's
+// login keychain, granting codesign access the way Xcode itself would.
+func installCertificate(certPath string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to determine home directory: %s", err)
+	}
+	keychainPath := filepath.Join(home, "Library", "Keychains", "login.keychain-db")
+
+	cmd := command.New("security", "import", certPath, "-k", keychainPath, "-T", "/usr/bin/codesign", "-T", "/usr/bin/security")
+	if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+		return fmt.Errorf("failed to import certificate into keychain: %s, output: %s", err, out)
+	}
+
+	return nil
+}
+
+// installProvisioningProfile copies a downloaded provisioning profile into
+// the directory Xcode reads profiles from.
+func installProvisioningProfile(profilePath string) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to determine home directory: %s", err)
+	}
+	profilesDir := filepath.Join(home, "Library", "MobileDevice", "Provisioning Profiles")
+
+	if err := os.MkdirAll(profilesDir, 0755); err != nil {
+		return fmt.Errorf("failed to create provisioning profiles directory: %s", err)
+	}
+
+	cmd := command.New("cp", profilePath, filepath.Join(profilesDir, filepath.Base(profilePath)))
+	if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+		return fmt.Errorf("failed to install provisioning profile: %s, output: %s", err, out)
+	}
+
+	return nil
+}
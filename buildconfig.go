@@ -0,0 +1,236 @@
+package main
+
+import "encoding/json"
+
+// IOSBuildConfigurationItem ...
+type IOSBuildConfigurationItem struct {
+	CodeSignIdentity    string `json:"codeSignIdentity,omitempty"`
+	ProvisioningProfile string `json:"provisioningProfile,omitempty"`
+	DevelopmentTeam     string `json:"developmentTeam,omitempty"`
+	PackageType         string `json:"packageType,omitempty"`
+
+	// Extra preserves any ios build.json keys Cordova understands that this
+	// step does not model explicitly, so merging this run's entry into an
+	// existing build.json never drops fields added by newer Cordova versions.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON round-trips Extra alongside the known fields above.
+func (i IOSBuildConfigurationItem) MarshalJSON() ([]byte, error) {
+	type alias IOSBuildConfigurationItem
+	return marshalWithExtra(alias(i), i.Extra)
+}
+
+// UnmarshalJSON captures any key this step does not model explicitly into Extra.
+func (i *IOSBuildConfigurationItem) UnmarshalJSON(data []byte) error {
+	type alias IOSBuildConfigurationItem
+	var a alias
+	extra, err := unmarshalWithExtra(data, &a, "codeSignIdentity", "provisioningProfile", "developmentTeam", "packageType")
+	if err != nil {
+		return err
+	}
+	*i = IOSBuildConfigurationItem(a)
+	i.Extra = extra
+	return nil
+}
+
+// mergeInto overlays item's non-zero fields onto base and returns the
+// result, so a field this run didn't set (e.g. codeSignIdentity when only
+// developmentTeam changed) doesn't clobber what base already had.
+func (i IOSBuildConfigurationItem) mergeInto(base IOSBuildConfigurationItem) IOSBuildConfigurationItem {
+	if i.CodeSignIdentity != "" {
+		base.CodeSignIdentity = i.CodeSignIdentity
+	}
+	if i.ProvisioningProfile != "" {
+		base.ProvisioningProfile = i.ProvisioningProfile
+	}
+	if i.DevelopmentTeam != "" {
+		base.DevelopmentTeam = i.DevelopmentTeam
+	}
+	if i.PackageType != "" {
+		base.PackageType = i.PackageType
+	}
+	base.Extra = mergeExtra(base.Extra, i.Extra)
+	return base
+}
+
+// AndroidBuildConfigurationItem ...
+type AndroidBuildConfigurationItem struct {
+	Keystore      string `json:"keystore,omitempty"`
+	StorePassword string `json:"storePassword,omitempty"`
+	Alias         string `json:"alias,omitempty"`
+	Password      string `json:"password,omitempty"`
+
+	// KeystoreType is the keystore format, e.g. "jks" or "pkcs12".
+	KeystoreType string `json:"keystoreType,omitempty"`
+	// SigningType selects the Android signing tool: "apksigner" or "jarsigner".
+	SigningType string `json:"signingType,omitempty"`
+	// PackageType selects the build artifact: "apk" or "bundle" (AAB).
+	PackageType string `json:"packageType,omitempty"`
+	// BuildFlag is passed through verbatim to `cordova build`, e.g. "--gradleArg=-Pandroid.injected.signing.store.file=...".
+	BuildFlag []string `json:"buildFlag,omitempty"`
+
+	// Extra preserves any android build.json keys Cordova understands that
+	// this step does not model explicitly, so merging this run's entry into
+	// an existing build.json never drops fields added by newer Cordova
+	// versions (e.g. releaseSigningPropertiesFile).
+	Extra map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON round-trips Extra alongside the known fields above.
+func (a AndroidBuildConfigurationItem) MarshalJSON() ([]byte, error) {
+	type alias AndroidBuildConfigurationItem
+	return marshalWithExtra(alias(a), a.Extra)
+}
+
+// UnmarshalJSON captures any key this step does not model explicitly into Extra.
+func (a *AndroidBuildConfigurationItem) UnmarshalJSON(data []byte) error {
+	type alias AndroidBuildConfigurationItem
+	var al alias
+	extra, err := unmarshalWithExtra(data, &al, "keystore", "storePassword", "alias", "password", "keystoreType", "signingType", "packageType", "buildFlag")
+	if err != nil {
+		return err
+	}
+	*a = AndroidBuildConfigurationItem(al)
+	a.Extra = extra
+	return nil
+}
+
+// mergeInto overlays item's non-zero fields onto base and returns the
+// result, so a field this run didn't set doesn't clobber what base already
+// had.
+func (a AndroidBuildConfigurationItem) mergeInto(base AndroidBuildConfigurationItem) AndroidBuildConfigurationItem {
+	if a.Keystore != "" {
+		base.Keystore = a.Keystore
+	}
+	if a.StorePassword != "" {
+		base.StorePassword = a.StorePassword
+	}
+	if a.Alias != "" {
+		base.Alias = a.Alias
+	}
+	if a.Password != "" {
+		base.Password = a.Password
+	}
+	if a.KeystoreType != "" {
+		base.KeystoreType = a.KeystoreType
+	}
+	if a.SigningType != "" {
+		base.SigningType = a.SigningType
+	}
+	if a.PackageType != "" {
+		base.PackageType = a.PackageType
+	}
+	if len(a.BuildFlag) > 0 {
+		base.BuildFlag = a.BuildFlag
+	}
+	base.Extra = mergeExtra(base.Extra, a.Extra)
+	return base
+}
+
+// BuildConfiguration ...
+type BuildConfiguration struct {
+	Android map[string]AndroidBuildConfigurationItem `json:"android,omitempty"`
+	IOS     map[string]IOSBuildConfigurationItem     `json:"ios,omitempty"`
+}
+
+// MergeInto deep-merges every configuration entry in buildConfig onto base,
+// field by field, this-run values winning on conflict, and returns the
+// merged result. Configuration entries already present in base but not
+// produced by this run (e.g. a checked-in "staging" entry) are left
+// untouched, and fields/Extra keys this run didn't set are preserved from
+// base rather than being dropped.
+func (buildConfig BuildConfiguration) MergeInto(base BuildConfiguration) BuildConfiguration {
+	if len(buildConfig.Android) > 0 {
+		if base.Android == nil {
+			base.Android = map[string]AndroidBuildConfigurationItem{}
+		}
+		for configuration, item := range buildConfig.Android {
+			base.Android[configuration] = item.mergeInto(base.Android[configuration])
+		}
+	}
+
+	if len(buildConfig.IOS) > 0 {
+		if base.IOS == nil {
+			base.IOS = map[string]IOSBuildConfigurationItem{}
+		}
+		for configuration, item := range buildConfig.IOS {
+			base.IOS[configuration] = item.mergeInto(base.IOS[configuration])
+		}
+	}
+
+	return base
+}
+
+// mergeExtra merges overlay's keys onto base, overlay winning on conflict,
+// without mutating either input map.
+func mergeExtra(base, overlay map[string]interface{}) map[string]interface{} {
+	if len(overlay) == 0 {
+		return base
+	}
+
+	merged := map[string]interface{}{}
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overlay {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// marshalWithExtra marshals known, then overlays any extra key not already
+// produced by known's own json tags.
+func marshalWithExtra(known interface{}, extra map[string]interface{}) ([]byte, error) {
+	knownBytes, err := json.Marshal(known)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(extra) == 0 {
+		return knownBytes, nil
+	}
+
+	var merged map[string]interface{}
+	if err := json.Unmarshal(knownBytes, &merged); err != nil {
+		return nil, err
+	}
+	if merged == nil {
+		merged = map[string]interface{}{}
+	}
+	for key, value := range extra {
+		if _, exists := merged[key]; !exists {
+			merged[key] = value
+		}
+	}
+
+	return json.Marshal(merged)
+}
+
+// unmarshalWithExtra unmarshals data into known, then returns every top-level
+// key in data not listed in knownKeys.
+func unmarshalWithExtra(data []byte, known interface{}, knownKeys ...string) (map[string]interface{}, error) {
+	if err := json.Unmarshal(data, known); err != nil {
+		return nil, err
+	}
+
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	isKnown := map[string]bool{}
+	for _, key := range knownKeys {
+		isKnown[key] = true
+	}
+
+	extra := map[string]interface{}{}
+	for key, value := range all {
+		if !isKnown[key] {
+			extra[key] = value
+		}
+	}
+
+	return extra, nil
+}
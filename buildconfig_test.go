@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildConfigurationMergeInto(t *testing.T) {
+	base := BuildConfiguration{
+		Android: map[string]AndroidBuildConfigurationItem{
+			"release": {
+				Keystore:      "/base/keystore.jks",
+				StorePassword: "base-store-password",
+				Extra:         map[string]interface{}{"releaseSigningPropertiesFile": "release-signing.properties"},
+			},
+			"staging": {
+				Keystore: "/base/staging.jks",
+			},
+		},
+	}
+
+	thisRun := BuildConfiguration{
+		Android: map[string]AndroidBuildConfigurationItem{
+			"release": {
+				Keystore: "/this-run/keystore.jks",
+				Alias:    "this-run-alias",
+			},
+		},
+	}
+
+	merged := thisRun.MergeInto(base)
+
+	release := merged.Android["release"]
+	if release.Keystore != "/this-run/keystore.jks" {
+		t.Errorf("release.Keystore = %q, want this run's value to win", release.Keystore)
+	}
+	if release.StorePassword != "base-store-password" {
+		t.Errorf("release.StorePassword = %q, want base's value preserved", release.StorePassword)
+	}
+	if release.Alias != "this-run-alias" {
+		t.Errorf("release.Alias = %q, want this run's value", release.Alias)
+	}
+	if !reflect.DeepEqual(release.Extra, map[string]interface{}{"releaseSigningPropertiesFile": "release-signing.properties"}) {
+		t.Errorf("release.Extra = %v, want base's Extra preserved", release.Extra)
+	}
+
+	if _, ok := merged.Android["staging"]; !ok {
+		t.Errorf("merged is missing the untouched staging entry from base")
+	}
+}
@@ -0,0 +1,32 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func verifySHA256(pth, expected string) error {
+	f, err := os.Open(pth)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for checksum verification: %s", pth, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return fmt.Errorf("failed to read %s for checksum verification: %s", pth, err)
+	}
+
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", pth, expected, actual)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+// Package download fetches a remote file to a local path, by URL scheme, with
+// optional sha256 checksum verification. New schemes can be added with
+// Register without touching callers.
+package download
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Fetcher retrieves the resource at rawURL and writes it to dstPath.
+type Fetcher interface {
+	Fetch(rawURL, dstPath string) error
+}
+
+var fetchers = map[string]Fetcher{
+	"http":             httpFetcher{},
+	"https":            httpFetcher{},
+	"file":             fileFetcher{},
+	"s3":               s3Fetcher{},
+	"gs":               gsFetcher{},
+	"bitrise-artifact": bitriseArtifactFetcher{},
+}
+
+// Register adds or overrides the Fetcher used for scheme.
+func Register(scheme string, fetcher Fetcher) {
+	fetchers[scheme] = fetcher
+}
+
+// File downloads rawURL to dstPath using the Fetcher registered for its
+// scheme, then verifies the result against sha256Checksum when it is
+// non-empty.
+func File(rawURL, dstPath, sha256Checksum string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse URL (%s): %s", rawURL, err)
+	}
+
+	scheme := u.Scheme
+	if scheme == "" {
+		scheme = "file"
+	}
+
+	fetcher, ok := fetchers[scheme]
+	if !ok {
+		return fmt.Errorf("unsupported URL scheme: %s", scheme)
+	}
+
+	if err := fetcher.Fetch(rawURL, dstPath); err != nil {
+		return fmt.Errorf("failed to fetch %s: %s", rawURL, err)
+	}
+
+	if sha256Checksum != "" {
+		if err := verifySHA256(dstPath, sha256Checksum); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package download
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type stubFetcher struct {
+	content string
+	err     error
+}
+
+func (f stubFetcher) Fetch(rawURL, dstPath string) error {
+	if f.err != nil {
+		return f.err
+	}
+	return ioutil.WriteFile(dstPath, []byte(f.content), 0644)
+}
+
+func TestFileDispatchesByScheme(t *testing.T) {
+	Register("stub", stubFetcher{content: "hello"})
+	defer delete(fetchers, "stub")
+
+	dstPath := filepath.Join(t.TempDir(), "out")
+	if err := File("stub://somewhere", dstPath, ""); err != nil {
+		t.Fatalf("File() returned an error: %s", err)
+	}
+
+	got, err := ioutil.ReadFile(dstPath)
+	if err != nil {
+		t.Fatalf("failed to read fetched file: %s", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("fetched content = %q, want %q", got, "hello")
+	}
+}
+
+func TestFileUnsupportedScheme(t *testing.T) {
+	if err := File("ftp://example.com/keystore.jks", filepath.Join(t.TempDir(), "out"), ""); err == nil {
+		t.Fatal("expected an error for an unsupported scheme, got nil")
+	}
+}
+
+func TestFileChecksumMismatch(t *testing.T) {
+	Register("stub", stubFetcher{content: "hello"})
+	defer delete(fetchers, "stub")
+
+	dstPath := filepath.Join(t.TempDir(), "out")
+	err := File("stub://somewhere", dstPath, "0000000000000000000000000000000000000000000000000000000000000000")
+	if err == nil {
+		t.Fatal("expected a checksum mismatch error, got nil")
+	}
+}
+
+func TestFileChecksumMatch(t *testing.T) {
+	Register("stub", stubFetcher{content: "hello"})
+	defer delete(fetchers, "stub")
+
+	// sha256("hello")
+	const sha256OfHello = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	dstPath := filepath.Join(t.TempDir(), "out")
+	err := File("stub://somewhere", dstPath, sha256OfHello)
+	if err != nil {
+		t.Fatalf("expected no error for a matching checksum, got: %s", err)
+	}
+}
+
+func TestFileFetchError(t *testing.T) {
+	Register("stub", stubFetcher{err: os.ErrNotExist})
+	defer delete(fetchers, "stub")
+
+	if err := File("stub://somewhere", filepath.Join(t.TempDir(), "out"), ""); err == nil {
+		t.Fatal("expected the underlying fetch error to propagate, got nil")
+	}
+}
@@ -0,0 +1,84 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	httpRetryCount   = 3
+	httpRetryBackoff = 2 * time.Second
+)
+
+// httpFetcher fetches http(s) URLs, following redirects (net/http's default,
+// safe, same-origin-aware behaviour), retrying transient failures and 5xx
+// responses with exponential backoff, and failing hard on any other non-2xx
+// response instead of writing the error body out as if it were the file.
+type httpFetcher struct{}
+
+func (httpFetcher) Fetch(rawURL, dstPath string) error {
+	var lastErr error
+
+	backoff := httpRetryBackoff
+	for attempt := 0; attempt <= httpRetryCount; attempt++ {
+		if attempt > 0 {
+			log.Warnf("download failed (%s), retrying in %s (attempt %d/%d)", lastErr, backoff, attempt, httpRetryCount)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		if err := fetchOnce(rawURL, dstPath); err != nil {
+			lastErr = err
+			if !isTransient(err) {
+				return err
+			}
+			continue
+		}
+
+		return nil
+	}
+
+	return lastErr
+}
+
+type transientError struct{ error }
+
+func isTransient(err error) bool {
+	_, ok := err.(transientError)
+	return ok
+}
+
+func fetchOnce(rawURL, dstPath string) error {
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		return transientError{err}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 == 5 {
+		return transientError{fmt.Errorf("server error: %s", resp.Status)}
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := out.Close(); err != nil {
+			log.Warnf("Failed to close file: %s, error: %s", dstPath, err)
+		}
+	}()
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
@@ -0,0 +1,112 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// fileFetcher copies a local file:// path to dstPath.
+type fileFetcher struct{}
+
+func (fileFetcher) Fetch(rawURL, dstPath string) error {
+	rawPth := strings.TrimPrefix(rawURL, "file://")
+	absPth, err := pathutil.AbsPath(rawPth)
+	if err != nil {
+		return fmt.Errorf("failed to expand path (%s): %s", rawPth, err)
+	}
+
+	in, err := os.Open(absPth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// s3Fetcher fetches s3:// URLs via the aws CLI, which is expected to already
+// be configured with credentials on the machine running the step.
+type s3Fetcher struct{}
+
+func (s3Fetcher) Fetch(rawURL, dstPath string) error {
+	cmd := command.New("aws", "s3", "cp", rawURL, dstPath)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("aws s3 cp failed: %s, output: %s", err, out)
+	}
+	return nil
+}
+
+// gsFetcher fetches gs:// URLs via the gsutil CLI, which is expected to
+// already be configured with credentials on the machine running the step.
+type gsFetcher struct{}
+
+func (gsFetcher) Fetch(rawURL, dstPath string) error {
+	cmd := command.New("gsutil", "cp", rawURL, dstPath)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("gsutil cp failed: %s, output: %s", err, out)
+	}
+	return nil
+}
+
+// bitriseArtifactFetcher fetches bitrise-artifact://<artifact-slug> URLs from
+// the currently running Bitrise build, authenticating with the build's API
+// token the way the autocodesign package talks to bitrise.io.
+type bitriseArtifactFetcher struct{}
+
+func (bitriseArtifactFetcher) Fetch(rawURL, dstPath string) error {
+	buildURL := os.Getenv("BITRISE_BUILD_URL")
+	buildToken := os.Getenv("BITRISE_BUILD_API_TOKEN")
+	if buildURL == "" || buildToken == "" {
+		return fmt.Errorf("bitrise-artifact:// URLs require a bitrise.io build (BITRISE_BUILD_URL / BITRISE_BUILD_API_TOKEN are not set)")
+	}
+
+	artifactSlug := strings.TrimPrefix(rawURL, "bitrise-artifact://")
+
+	req, err := http.NewRequest(http.MethodGet, buildURL+"/artifacts/"+artifactSlug, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+buildToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
@@ -3,8 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -15,9 +14,16 @@ import (
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-utils/pathutil"
 	"github.com/bitrise-tools/go-steputils/stepconf"
+	"github.com/willadamskeane/steps-generate-cordova-build-configuration/autocodesign"
+	"github.com/willadamskeane/steps-generate-cordova-build-configuration/download"
 )
 
 // ConfigsModel ...
+// Configuration is a `|` separated list of configuration names, e.g.
+// `debug|release`, so a single run can emit more than one entry into
+// build.json. Every per-configuration credential input accepts either a
+// single value (applied to every configuration) or a `|` separated list of
+// `configuration:value` pairs, e.g. `debug:com.example.debug|release:com.example.release`.
 type ConfigsModel struct {
 	Configuration string `env:"configuration,required"`
 
@@ -27,53 +33,130 @@ type ConfigsModel struct {
 	PackageType         string `env:"package_type"`
 
 	KeystoreURL        string          `env:"keystore_url"`
+	KeystoreURLSHA256  string          `env:"keystore_url_sha256"`
 	KeystorePassword   stepconf.Secret `env:"keystore_password"`
 	KeystoreAlias      string          `env:"keystore_alias"`
 	PrivateKeyPassword stepconf.Secret `env:"private_key_password"`
-}
 
-// IOSBuildConfigurationItem ...
-type IOSBuildConfigurationItem struct {
-	CodeSignIdentity    string `json:"codeSignIdentity,omitempty"`
-	ProvisioningProfile string `json:"provisioningProfile,omitempty"`
-	DevelopmentTeam     string `json:"developmentTeam,omitempty"`
-	PackageType         string `json:"packageType,omitempty"`
+	// AndroidPackageType, AndroidSigningType and AndroidKeystoreType mirror
+	// Cordova-Android's own build.json schema, letting users produce a signed
+	// AAB (packageType: bundle) for Play Store upload directly from this step.
+	// Like the other per-configuration inputs above, each also accepts a `|`
+	// separated `configuration:value` list, so they can't be restricted to a
+	// fixed opt-list of bare enum values.
+	AndroidPackageType  string `env:"android_package_type"`
+	AndroidSigningType  string `env:"android_signing_type"`
+	AndroidKeystoreType string `env:"android_keystore_type"`
+	// AndroidBuildFlags is a newline separated list of extra flags passed
+	// through to `cordova build` as the android buildFlag array. Like the
+	// other per-configuration inputs above, it also accepts a `|` separated
+	// `configuration:value` list, each value itself newline separated, so a
+	// release AAB can pass signing flags a debug build doesn't need.
+	AndroidBuildFlags string `env:"android_build_flags"`
+
+	// AutomaticCodeSigning, when set, resolves the credentials of every
+	// configuration that has no provisioning profile (iOS) or no keystore URL
+	// (Android) through the Bitrise build's connected Apple Developer Portal
+	// and Google Play accounts. A code sign identity, development team,
+	// keystore password, alias or private key password given explicitly is
+	// kept over the resolved value.
+	AutomaticCodeSigning bool   `env:"automatic_code_signing,opt[true,false]"`
+	ConfigXMLPath        string `env:"config_xml_path"`
+
+	// OutputDir and OutputFilename control where the generated build.json is
+	// written, so that downstream steps (e.g. Deploy-to-Bitrise.io) can pick
+	// it up without extra glue. OutputDir defaults to $BITRISE_DEPLOY_DIR.
+	OutputDir      string `env:"output_dir"`
+	OutputFilename string `env:"output_filename"`
+
+	// BaseBuildJSONPath, when set, points at a build.json already checked
+	// into the project (e.g. with dev/staging/release entries). This run's
+	// Android/iOS configuration entries are deep-merged into it, this run's
+	// values winning on conflict, instead of overwriting the whole file.
+	BaseBuildJSONPath string `env:"base_build_json_path"`
 }
 
-// AndroidBuildConfigurationItem ...
-type AndroidBuildConfigurationItem struct {
-	Keystore      string `json:"keystore,omitempty"`
-	StorePassword string `json:"storePassword,omitempty"`
-	Alias         string `json:"alias,omitempty"`
-	Password      string `json:"password,omitempty"`
+// configurations returns the list of configuration names this run should
+// generate entries for, in the order they were specified.
+func (configs ConfigsModel) configurations() []string {
+	return strings.Split(configs.Configuration, "|")
 }
 
-// BuildConfiguration ...
-type BuildConfiguration struct {
-	Android map[string]AndroidBuildConfigurationItem `json:"android,omitempty"`
-	IOS     map[string]IOSBuildConfigurationItem     `json:"ios,omitempty"`
+// perConfigurationValue returns the value that applies to configuration out
+// of raw (see ConfigsModel). raw is only parsed as a `|` separated list of
+// `configurationName:value` pairs when it unambiguously is one: every
+// pipe-separated entry must start with one of knownConfigurations followed by
+// a colon. Otherwise raw is treated as a single plain value shared by every
+// configuration. This keeps plain values that merely contain a colon (an
+// "https://" keystore_url, a code_sign_identity like
+// "iPhone Distribution: My Company") from being misparsed as a per-
+// configuration list.
+func perConfigurationValue(raw, configuration string, knownConfigurations []string) string {
+	values, ok := parsePerConfigurationList(raw, knownConfigurations)
+	if !ok {
+		return raw
+	}
+	return values[configuration]
 }
 
-func download(url, pth string) error {
-	out, err := os.Create(pth)
-	defer func() {
-		if err := out.Close(); err != nil {
-			log.Warnf("Failed to close file: %s, error: %s", out, err)
+func parsePerConfigurationList(raw string, knownConfigurations []string) (map[string]string, bool) {
+	if raw == "" || !strings.Contains(raw, ":") {
+		return nil, false
+	}
+
+	values := map[string]string{}
+	for _, entry := range strings.Split(raw, "|") {
+		split := strings.SplitN(entry, ":", 2)
+		if len(split) != 2 || !isKnownConfiguration(split[0], knownConfigurations) {
+			return nil, false
 		}
-	}()
+		values[split[0]] = split[1]
+	}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
+	return values, true
+}
+
+// iosPackageTypeForConfiguration resolves PackageType for configuration and
+// reports whether iOS should be built for it at all. It is false when the
+// resolved value is the explicit "none" opt-out, or when PackageType is a
+// `configuration:value` list that simply omits configuration, which is an
+// implicit opt-out for that configuration only (e.g.
+// `debug:none|release:app-store`, or `release:app-store` on its own when
+// running debug and release together).
+func iosPackageTypeForConfiguration(raw, configuration string, knownConfigurations []string) (string, bool) {
+	values, isList := parsePerConfigurationList(raw, knownConfigurations)
+	packageType := raw
+	if isList {
+		packageType = values[configuration]
+	}
+	if packageType == "none" || (isList && packageType == "") {
+		return "", false
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Warnf("Failed to close response body, error: %s", err)
+	return packageType, true
+}
+
+func isKnownConfiguration(name string, knownConfigurations []string) bool {
+	for _, configuration := range knownConfigurations {
+		if configuration == name {
+			return true
 		}
-	}()
+	}
+	return false
+}
 
-	_, err = io.Copy(out, resp.Body)
-	return err
+// validateOneOf fails unless value is empty or one of allowed, used for the
+// enum-like android_* inputs which can't use stepconf's own opt-list
+// validation since they also accept the `configuration:value` list syntax.
+func validateOneOf(inputName, value string, allowed ...string) error {
+	if value == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid %s: %s (available: %s)", inputName, value, strings.Join(allowed, ", "))
 }
 
 func exportEnvironmentWithEnvman(keyStr, valueStr string) error {
@@ -103,60 +186,178 @@ func main() {
 
 	fmt.Println()
 
+	configurations := configs.configurations()
+
+	configXMLPath := configs.ConfigXMLPath
+	if configXMLPath == "" {
+		configXMLPath = "config.xml"
+	}
+
+	var autocodesignClient *autocodesign.Client
+	var bundleID string
+	resolveAutocodesignClient := func() *autocodesign.Client {
+		if autocodesignClient == nil {
+			client, err := autocodesign.NewClient()
+			if err != nil {
+				fail("Failed to set up automatic code signing, error: %s", err)
+			}
+			autocodesignClient = client
+		}
+		return autocodesignClient
+	}
+	resolveBundleID := func() string {
+		if bundleID == "" {
+			id, err := autocodesign.BundleID(configXMLPath)
+			if err != nil {
+				fail("Failed to resolve bundle ID for automatic code signing, error: %s", err)
+			}
+			bundleID = id
+		}
+		return bundleID
+	}
+
 	// Android Build Config
-	if configs.KeystoreURL != "" {
+	if configs.KeystoreURL != "" || configs.AutomaticCodeSigning {
 		log.Infof("Adding android build config")
 
-		keystorePath := ""
-		if strings.HasPrefix(configs.KeystoreURL, "file://") {
-			rawPth := strings.TrimPrefix(configs.KeystoreURL, "file://")
-			absPth, err := pathutil.AbsPath(rawPth)
-			if err != nil {
-				fail("Failed to expand path (%s), error: %s", rawPth, err)
+		androidBuildConfigs := map[string]AndroidBuildConfigurationItem{}
+
+		for i, configuration := range configurations {
+			keystoreURL := perConfigurationValue(configs.KeystoreURL, configuration, configurations)
+			keystoreSHA256 := perConfigurationValue(configs.KeystoreURLSHA256, configuration, configurations)
+			storePassword := perConfigurationValue(string(configs.KeystorePassword), configuration, configurations)
+			alias := perConfigurationValue(configs.KeystoreAlias, configuration, configurations)
+			privateKeyPassword := perConfigurationValue(string(configs.PrivateKeyPassword), configuration, configurations)
+
+			keystorePath := ""
+			switch {
+			case keystoreURL == "" && configs.AutomaticCodeSigning:
+				log.Printf("resolving keystore for %s via automatic code signing", configuration)
+
+				credentials, err := resolveAutocodesignClient().ResolveAndroid(resolveBundleID(), configuration, tmpDir)
+				if err != nil {
+					fail("Failed to resolve android code signing credentials, error: %s", err)
+				}
+				keystorePath = credentials.KeystorePath
+				if storePassword == "" {
+					storePassword = credentials.KeystorePassword
+				}
+				if alias == "" {
+					alias = credentials.KeystoreAlias
+				}
+				if privateKeyPassword == "" {
+					privateKeyPassword = credentials.PrivateKeyPassword
+				}
+			case keystoreURL == "":
+				continue
+			default:
+				log.Printf("fetching keystore")
+
+				keystorePath = path.Join(tmpDir, fmt.Sprintf("keystore_%d.jks", i))
+				if err := download.File(keystoreURL, keystorePath, keystoreSHA256); err != nil {
+					fail("Failed to fetch keystore, error: %s", err)
+				}
 			}
-			keystorePath = absPth
-		} else {
-			log.Printf("download keystore")
 
-			keystorePath = path.Join(tmpDir, "keystore.jks")
-			if err := download(configs.KeystoreURL, keystorePath); err != nil {
-				fail("Failed to download keystore, error: %s", err)
+			keystoreType := perConfigurationValue(configs.AndroidKeystoreType, configuration, configurations)
+			signingType := perConfigurationValue(configs.AndroidSigningType, configuration, configurations)
+			packageType := perConfigurationValue(configs.AndroidPackageType, configuration, configurations)
+
+			if err := validateOneOf("android_keystore_type", keystoreType, "jks", "pkcs12"); err != nil {
+				fail("%s", err)
+			}
+			if err := validateOneOf("android_signing_type", signingType, "apksigner", "jarsigner"); err != nil {
+				fail("%s", err)
+			}
+			if err := validateOneOf("android_package_type", packageType, "apk", "bundle"); err != nil {
+				fail("%s", err)
 			}
-		}
 
-		androidBuildConfig := AndroidBuildConfigurationItem{
-			Keystore:      keystorePath,
-			StorePassword: string(configs.KeystorePassword),
-			Alias:         configs.KeystoreAlias,
-			Password:      string(configs.PrivateKeyPassword),
-		}
+			var androidBuildFlags []string
+			for _, flag := range strings.Split(perConfigurationValue(configs.AndroidBuildFlags, configuration, configurations), "\n") {
+				if flag = strings.TrimSpace(flag); flag != "" {
+					androidBuildFlags = append(androidBuildFlags, flag)
+				}
+			}
 
-		buildConfig.Android = map[string]AndroidBuildConfigurationItem{
-			configs.Configuration: androidBuildConfig,
+			androidBuildConfigs[configuration] = AndroidBuildConfigurationItem{
+				Keystore:      keystorePath,
+				StorePassword: storePassword,
+				Alias:         alias,
+				Password:      privateKeyPassword,
+				KeystoreType:  keystoreType,
+				SigningType:   signingType,
+				PackageType:   packageType,
+				BuildFlag:     androidBuildFlags,
+			}
 		}
+
+		buildConfig.Android = androidBuildConfigs
 	}
 
 	// iOS Build Config
-	if configs.PackageType != "none" {
-		log.Infof("Adding ios build config")
+	iosBuildConfigs := map[string]IOSBuildConfigurationItem{}
 
-		iosBuildConfig := IOSBuildConfigurationItem{
-			CodeSignIdentity:    configs.CodeSignIdentity,
-			ProvisioningProfile: configs.ProvisioningProfile,
-			DevelopmentTeam:     configs.DevelopmentTeam,
-			PackageType:         configs.PackageType,
+	for _, configuration := range configurations {
+		packageType, iosEnabled := iosPackageTypeForConfiguration(configs.PackageType, configuration, configurations)
+		if !iosEnabled {
+			continue
 		}
 
-		buildConfig.IOS = map[string]IOSBuildConfigurationItem{
-			configs.Configuration: iosBuildConfig,
+		codeSignIdentity := perConfigurationValue(configs.CodeSignIdentity, configuration, configurations)
+		provisioningProfile := perConfigurationValue(configs.ProvisioningProfile, configuration, configurations)
+		developmentTeam := perConfigurationValue(configs.DevelopmentTeam, configuration, configurations)
+
+		if provisioningProfile == "" && configs.AutomaticCodeSigning {
+			log.Printf("resolving provisioning profile for %s via automatic code signing", configuration)
+
+			credentials, err := resolveAutocodesignClient().ResolveIOS(resolveBundleID(), configuration, tmpDir)
+			if err != nil {
+				fail("Failed to resolve ios code signing credentials, error: %s", err)
+			}
+			provisioningProfile = credentials.ProvisioningProfilePath
+			if codeSignIdentity == "" {
+				codeSignIdentity = credentials.CodeSignIdentity
+			}
+			if developmentTeam == "" {
+				developmentTeam = credentials.DevelopmentTeam
+			}
+		}
+
+		iosBuildConfigs[configuration] = IOSBuildConfigurationItem{
+			CodeSignIdentity:    codeSignIdentity,
+			ProvisioningProfile: provisioningProfile,
+			DevelopmentTeam:     developmentTeam,
+			PackageType:         packageType,
 		}
 	}
 
+	if len(iosBuildConfigs) > 0 {
+		log.Infof("Adding ios build config")
+		buildConfig.IOS = iosBuildConfigs
+	}
+
 	if len(buildConfig.Android) == 0 && len(buildConfig.IOS) == 0 {
 		log.Warnf("No ios nor android build config parameters specified, nothing to generate...")
 		os.Exit(0)
 	}
 
+	if configs.BaseBuildJSONPath != "" {
+		log.Infof("Merging into base build.json: %s", configs.BaseBuildJSONPath)
+
+		baseBytes, err := ioutil.ReadFile(configs.BaseBuildJSONPath)
+		if err != nil {
+			fail("Failed to read base build.json (%s), error: %s", configs.BaseBuildJSONPath, err)
+		}
+
+		var base BuildConfiguration
+		if err := json.Unmarshal(baseBytes, &base); err != nil {
+			fail("Failed to parse base build.json (%s), error: %s", configs.BaseBuildJSONPath, err)
+		}
+
+		buildConfig = buildConfig.MergeInto(base)
+	}
+
 	// Generating build.json
 	fmt.Println()
 	log.Infof("Generating config file")
@@ -169,15 +370,17 @@ func main() {
 	{
 		printableConfigBytes := append([]byte{}, buildConfigBytes...)
 
-		if config, ok := buildConfig.Android[configs.Configuration]; ok {
-			if config.Password != "" {
-				config.Password = "*****"
-			}
-			if config.StorePassword != "" {
-				config.StorePassword = "*****"
-			}
+		if len(buildConfig.Android) > 0 {
+			for configuration, config := range buildConfig.Android {
+				if config.Password != "" {
+					config.Password = "*****"
+				}
+				if config.StorePassword != "" {
+					config.StorePassword = "*****"
+				}
 
-			buildConfig.Android[configs.Configuration] = config
+				buildConfig.Android[configuration] = config
+			}
 
 			printableConfigBytes, err = json.MarshalIndent(buildConfig, "", "  ")
 			if err != nil {
@@ -189,7 +392,23 @@ func main() {
 		log.Printf(string(printableConfigBytes))
 	}
 
-	buildConfigPth := filepath.Join(tmpDir, "build.json")
+	outputDir := configs.OutputDir
+	if outputDir == "" {
+		outputDir = os.Getenv("BITRISE_DEPLOY_DIR")
+	}
+	if outputDir == "" {
+		outputDir = tmpDir
+	}
+	if err := pathutil.EnsureDirExist(outputDir); err != nil {
+		fail("Failed to create output dir (%s), error: %s", outputDir, err)
+	}
+
+	outputFilename := configs.OutputFilename
+	if outputFilename == "" {
+		outputFilename = "build.json"
+	}
+
+	buildConfigPth := filepath.Join(outputDir, outputFilename)
 	if err := fileutil.WriteBytesToFile(buildConfigPth, buildConfigBytes); err != nil {
 		fail("Failed to write build.json file, error: %s", err)
 	}
@@ -197,5 +416,8 @@ func main() {
 	if err := exportEnvironmentWithEnvman("BITRISE_CORDOVA_BUILD_CONFIGURATION", buildConfigPth); err != nil {
 		fail("Failed to export BITRISE_CORDOVA_BUILD_CONFIGURATION, error: %s", err)
 	}
+	if err := exportEnvironmentWithEnvman("BITRISE_CORDOVA_BUILD_CONFIGURATION_CONTENT", string(buildConfigBytes)); err != nil {
+		fail("Failed to export BITRISE_CORDOVA_BUILD_CONFIGURATION_CONTENT, error: %s", err)
+	}
 	log.Donef("The build.json path is now available in the Environment Variable: BITRISE_CORDOVA_BUILD_CONFIGURATION (value: %s)", buildConfigPth)
 }
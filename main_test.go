@@ -0,0 +1,124 @@
+package main
+
+import "testing"
+
+func TestPerConfigurationValue(t *testing.T) {
+	configurations := []string{"debug", "release"}
+
+	tests := []struct {
+		name          string
+		raw           string
+		configuration string
+		want          string
+	}{
+		{
+			name:          "plain value applies to every configuration",
+			raw:           "https://example.com/keystore.jks",
+			configuration: "debug",
+			want:          "https://example.com/keystore.jks",
+		},
+		{
+			name:          "plain value containing a colon is not mistaken for a list",
+			raw:           "iPhone Distribution: My Company",
+			configuration: "debug",
+			want:          "iPhone Distribution: My Company",
+		},
+		{
+			name:          "bitrise-artifact URL is not mistaken for a list",
+			raw:           "bitrise-artifact://abc123",
+			configuration: "release",
+			want:          "bitrise-artifact://abc123",
+		},
+		{
+			name:          "per-configuration list resolves the matching entry",
+			raw:           "debug:/path/to/debug.jks|release:/path/to/release.jks",
+			configuration: "release",
+			want:          "/path/to/release.jks",
+		},
+		{
+			name:          "per-configuration list with an unmatched configuration is empty",
+			raw:           "debug:/path/to/debug.jks",
+			configuration: "release",
+			want:          "",
+		},
+		{
+			name:          "empty raw is empty",
+			raw:           "",
+			configuration: "debug",
+			want:          "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := perConfigurationValue(tt.raw, tt.configuration, configurations)
+			if got != tt.want {
+				t.Errorf("perConfigurationValue(%q, %q) = %q, want %q", tt.raw, tt.configuration, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIOSPackageTypeForConfiguration(t *testing.T) {
+	configurations := []string{"debug", "release"}
+
+	tests := []struct {
+		name            string
+		raw             string
+		configuration   string
+		wantPackageType string
+		wantEnabled     bool
+	}{
+		{
+			name:            "plain package type applies to every configuration",
+			raw:             "app-store",
+			configuration:   "debug",
+			wantPackageType: "app-store",
+			wantEnabled:     true,
+		},
+		{
+			name:          "plain none opts every configuration out",
+			raw:           "none",
+			configuration: "release",
+			wantEnabled:   false,
+		},
+		{
+			name:          "explicit none in a per-configuration list opts that configuration out",
+			raw:           "debug:none|release:app-store",
+			configuration: "debug",
+			wantEnabled:   false,
+		},
+		{
+			name:            "the other configuration in the same list is unaffected",
+			raw:             "debug:none|release:app-store",
+			configuration:   "release",
+			wantPackageType: "app-store",
+			wantEnabled:     true,
+		},
+		{
+			name:          "a configuration omitted from a per-configuration list is opted out",
+			raw:           "release:app-store",
+			configuration: "debug",
+			wantEnabled:   false,
+		},
+		{
+			name:            "an unset package type still enables ios, e.g. for automatic code signing alone",
+			raw:             "",
+			configuration:   "debug",
+			wantPackageType: "",
+			wantEnabled:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPackageType, gotEnabled := iosPackageTypeForConfiguration(tt.raw, tt.configuration, configurations)
+			if gotEnabled != tt.wantEnabled {
+				t.Fatalf("iosPackageTypeForConfiguration(%q, %q) enabled = %v, want %v", tt.raw, tt.configuration, gotEnabled, tt.wantEnabled)
+			}
+			if gotEnabled && gotPackageType != tt.wantPackageType {
+				t.Errorf("iosPackageTypeForConfiguration(%q, %q) = %q, want %q", tt.raw, tt.configuration, gotPackageType, tt.wantPackageType)
+			}
+		})
+	}
+}